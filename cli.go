@@ -41,43 +41,222 @@ If one invokes help on a specific command, then details for that command are sho
 	  -update   If specified, and the user already exists, then behave identically
 	            to 'setpassword'. If this is not specified, and the identity
 	            already exists, then the function returns with an error.
-
 */
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+)
+
+// Sentinel errors returned by App.RunArgs. Use errors.Is to test for them, since they are
+// typically wrapped with details about the specific command or option that triggered them.
+var (
+	ErrUnknownCommand = errors.New("unknown command")
+	ErrBadOption      = errors.New("bad option")
+	ErrMissingArg     = errors.New("missing argument")
+	ErrHelpRequested  = errors.New("help requested")
 )
 
+// ExecError wraps the exit code returned by a command's ExecFunc. App.RunArgs returns one of
+// these instead of nil when exec completes but reports failure, so that App.Run can still
+// surface the original exit code to its caller.
+type ExecError struct {
+	Code int
+}
+
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("command exited with code %d", e.Code)
+}
+
+// The type of value that an Option holds. This determines how App.Run validates
+// and parses the raw string that the user typed on the command line.
+type OptionType int
+
+const (
+	OptionTypeBool     OptionType = iota // No value, eg -z
+	OptionTypeString                     // Any string, eg -config=file
+	OptionTypeInt                        // Parsed with strconv.Atoi
+	OptionTypeFloat                      // Parsed with strconv.ParseFloat
+	OptionTypeDuration                   // Parsed with time.ParseDuration
+	OptionTypeEnum                       // Must be one of Option.Choices
+	OptionTypeSlice                      // May be repeated on the command line; values accumulate
+)
+
+// Separates the individual values of a repeated OptionTypeSlice option, once joined
+// together inside an OptionSet. This is a control character, so it can't collide
+// with a value a user actually typed.
+const sliceValueSep = "\x1f"
+
 // An option to a command
 type Option struct {
 	Key         string // Must be present. Option is entered as -Key, or -Key=Value
 	Value       string // If empty, then this is a boolean option, specified as -Key. If not empty, then this is a key/value option, specified as -Key=Value
 	Description string
+	Short       rune                         // Single-character short form, eg 'n' for -n. Only recognized when App.Style is StylePosix. Zero if there is no short form.
+	Type        OptionType                   // Defaults to OptionTypeBool or OptionTypeString, depending on which constructor was used
+	Choices     []string                     // Valid values when Type is OptionTypeEnum
+	Required    bool                         // If true, App.Run aborts before calling exec unless a value is supplied by the command line, EnvVar, or Default
+	Default     string                       // Used if the option is absent from the command line, and (if EnvVar is set) EnvVar is also unset. For a bool option, any non-empty Default just sets it, since a bool option carries no value
+	EnvVar      string                       // If set, and the option is absent from the command line, App.Run falls back to the value of this environment variable. For a bool option, the option is simply set when EnvVar is present in the environment, regardless of its value
+	Complete    func(prefix string) []string // Optional shell-completion hook, used by the hidden "__complete" command to suggest values for this option
+}
+
+func findOption(options []*Option, name string) *Option {
+	for _, opt := range options {
+		if opt.Key == name {
+			return opt
+		}
+	}
+	return nil
+}
+
+func findOptionShort(options []*Option, short rune) *Option {
+	for _, opt := range options {
+		if opt.Short == short {
+			return opt
+		}
+	}
+	return nil
 }
 
-func findOption(options []Option, name string) *Option {
-	for i := range options {
-		if options[i].Key == name {
-			return &options[i]
+// Appends "(required)"/"(default: X)"/"(env: X)" annotations to opt.Description. Used by both
+// ShowHelp and doc.go, so that generated man pages and Markdown stay consistent with the help
+// shown in the terminal.
+func annotateDescription(opt *Option) string {
+	desc := opt.Description
+	if opt.Required {
+		desc += " (required)"
+	}
+	if opt.Default != "" {
+		desc += fmt.Sprintf(" (default: %v)", opt.Default)
+	}
+	if opt.EnvVar != "" {
+		desc += fmt.Sprintf(" (env: %v)", opt.EnvVar)
+	}
+	return desc
+}
+
+// Checks 'value' against the constraints implied by opt.Type, returning a description
+// of the problem if it doesn't fit (eg a non-numeric value for an OptionTypeInt option).
+func validateOptionValue(opt *Option, value string) error {
+	switch opt.Type {
+	case OptionTypeInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("expects an integer value, got '%v'", value)
+		}
+	case OptionTypeFloat:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("expects a numeric value, got '%v'", value)
 		}
+	case OptionTypeDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("expects a duration value (eg '30s'), got '%v'", value)
+		}
+	case OptionTypeEnum:
+		for _, choice := range opt.Choices {
+			if choice == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("expects one of [%v], got '%v'", strings.Join(opt.Choices, ", "), value)
 	}
 	return nil
 }
 
-// Command execution callback.
+// The parsed options for a single command invocation, keyed by Option.Key. A bare
+// boolean option that was supplied is present with an empty string value; use Has
+// to test for that case. Values for OptionTypeSlice options are joined with an
+// internal separator - use StringSlice to retrieve them individually.
+type OptionSet map[string]string
+
+// merge records a parsed value for 'key'. If 'key' was already set (eg a repeated
+// -include=a.go -include=b.go slice option), the new value is appended rather than
+// overwriting the old one.
+func (o OptionSet) merge(key, value string) {
+	if existing, ok := o[key]; ok {
+		o[key] = existing + sliceValueSep + value
+	} else {
+		o[key] = value
+	}
+}
+
+// Has returns true if 'key' was supplied on the command line, whether or not it carries a value
+func (o OptionSet) Has(key string) bool {
+	_, ok := o[key]
+	return ok
+}
+
+// String returns the raw string value of 'key', or "" if it was not supplied
+func (o OptionSet) String(key string) string {
+	return o[key]
+}
+
+// Int parses the value of 'key' as an integer
+func (o OptionSet) Int(key string) (int, error) {
+	return strconv.Atoi(o[key])
+}
+
+// Float parses the value of 'key' as a 64-bit float
+func (o OptionSet) Float(key string) (float64, error) {
+	return strconv.ParseFloat(o[key], 64)
+}
+
+// Duration parses the value of 'key' with time.ParseDuration
+func (o OptionSet) Duration(key string) (time.Duration, error) {
+	return time.ParseDuration(o[key])
+}
+
+// StringSlice returns the values that were accumulated for a repeated (OptionTypeSlice) option.
+// Returns nil if 'key' was not supplied.
+func (o OptionSet) StringSlice(key string) []string {
+	value, ok := o[key]
+	if !ok {
+		return nil
+	}
+	return strings.Split(value, sliceValueSep)
+}
+
+// Command execution callback. The returned int becomes the process exit code when invoked via
+// App.Run; return 0 for success.
 // It is often easiest to implement a number of commands as a single big function with a switch statement on 'cmd'.
-type ExecFunc func(cmd string, args []string, options map[string]string)
+type ExecFunc func(cmd string, args []string, options OptionSet) int
 
-// A top-level command
+// A command, which may either be a top-level command, or a subcommand of another Command
 type Command struct {
 	Name        string
 	Description string
-	Args        []string // Mandatory arguments. To specify a variable number of arguments, write "...values" on the last argument. The name after the three dots can be anything.
-	Options     []Option // Optional arguments
-	Exec        ExecFunc // If this is nil, then App.DefaultExec is called
+	Args        []string   // Mandatory arguments. To specify a variable number of arguments, write "...values" on the last argument. The name after the three dots can be anything.
+	Options     []*Option  // Optional arguments. These are added to the options inherited from parent commands and the app itself.
+	Exec        ExecFunc   // If this is nil, and there are no Commands, then App.DefaultExec is called. If this is nil and there are Commands, then running this command alone shows its help.
+	Commands    []*Command // Subcommands of this command, eg "remote add" has "add" as a subcommand of "remote"
+	parent      *Command   // nil for top-level commands
+}
+
+// Add a subcommand to this command, eg Command "remote" might add subcommands "add" and "remove"
+func (c *Command) AddCommand(name, description string, args ...string) *Command {
+	child := &Command{
+		Name:        name,
+		Description: description,
+		Args:        args,
+		parent:      c,
+	}
+	c.Commands = append(c.Commands, child)
+	return child
+}
+
+func findCommand(cmds []*Command, name string) *Command {
+	for _, c := range cmds {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
 }
 
 func isVarArgs(args []string) bool {
@@ -95,126 +274,532 @@ func (c *Command) ExtraDescription() string {
 }
 
 // Add a command-specific bool option (such as -z)
-func (c *Command) AddBoolOption(name, description string) {
-	opt := Option{
+// Returns 'opt', which has already been appended to 'options'. The returned pointer is
+// safe to set Required/Default/EnvVar on, since it points at the slice element rather
+// than the caller's local copy.
+func addOption(options *[]*Option, opt Option) *Option {
+	*options = append(*options, &opt)
+	return &opt
+}
+
+// Add a command-specific bool option (such as -z). Returns the new Option so that
+// Required/Default/EnvVar can be set on it, eg c.AddBoolOption(...).Required = true
+func (c *Command) AddBoolOption(name, description string) *Option {
+	return addOption(&c.Options, Option{
 		Key:         name,
 		Description: description,
-	}
-	c.Options = append(c.Options, opt)
+	})
 }
 
-// Add a command-specific value option (such as -c=config_file)
-func (c *Command) AddValueOption(name, value, description string) {
-	opt := Option{
+// Add a command-specific value option (such as -c=config_file). Returns the new Option so that
+// Required/Default/EnvVar can be set on it, eg c.AddValueOption(...).Required = true
+func (c *Command) AddValueOption(name, value, description string) *Option {
+	return addOption(&c.Options, Option{
 		Key:         name,
 		Value:       value,
 		Description: description,
-	}
-	c.Options = append(c.Options, opt)
+		Type:        OptionTypeString,
+	})
+}
+
+// Add a command-specific integer value option (such as -count=3). App.Run rejects a non-integer
+// value before exec is called. Returns the new Option so that Required/Default/EnvVar can be set on it.
+func (c *Command) AddIntOption(name, value, description string) *Option {
+	return addOption(&c.Options, Option{
+		Key:         name,
+		Value:       value,
+		Description: description,
+		Type:        OptionTypeInt,
+	})
+}
+
+// Add a command-specific floating-point value option (such as -ratio=0.5). App.Run rejects a non-numeric
+// value before exec is called. Returns the new Option so that Required/Default/EnvVar can be set on it.
+func (c *Command) AddFloatOption(name, value, description string) *Option {
+	return addOption(&c.Options, Option{
+		Key:         name,
+		Value:       value,
+		Description: description,
+		Type:        OptionTypeFloat,
+	})
+}
+
+// Add a command-specific duration value option (such as -timeout=30s), parsed with time.ParseDuration.
+// App.Run rejects an unparseable value before exec is called. Returns the new Option so that
+// Required/Default/EnvVar can be set on it.
+func (c *Command) AddDurationOption(name, value, description string) *Option {
+	return addOption(&c.Options, Option{
+		Key:         name,
+		Value:       value,
+		Description: description,
+		Type:        OptionTypeDuration,
+	})
+}
+
+// Add a command-specific enum value option (such as -mode=fast), restricted to one of 'choices'.
+// App.Run rejects any other value before exec is called. Returns the new Option so that
+// Required/Default/EnvVar can be set on it.
+func (c *Command) AddEnumOption(name string, choices []string, description string) *Option {
+	return addOption(&c.Options, Option{
+		Key:         name,
+		Value:       strings.Join(choices, "|"),
+		Description: description,
+		Type:        OptionTypeEnum,
+		Choices:     choices,
+	})
+}
+
+// Add a command-specific repeatable value option (such as -include=a.go -include=b.go). Retrieve the
+// accumulated values with OptionSet.StringSlice. Returns the new Option so that Required/Default/EnvVar
+// can be set on it.
+func (c *Command) AddSliceOption(name, value, description string) *Option {
+	return addOption(&c.Options, Option{
+		Key:         name,
+		Value:       value,
+		Description: description,
+		Type:        OptionTypeSlice,
+	})
+}
+
+// Add a command-specific bool option with a short form (such as -v / --verbose). Only recognized
+// when App.Style is StylePosix. Returns the new Option so that Required/Default/EnvVar can be set on it.
+func (c *Command) AddBoolOptionShort(name string, short rune, description string) *Option {
+	return addOption(&c.Options, Option{
+		Key:         name,
+		Description: description,
+		Short:       short,
+	})
+}
+
+// Add a command-specific value option with a short form (such as -o file / --output=file). Only
+// recognized when App.Style is StylePosix. Returns the new Option so that Required/Default/EnvVar
+// can be set on it.
+func (c *Command) AddValueOptionShort(name string, short rune, value, description string) *Option {
+	return addOption(&c.Options, Option{
+		Key:         name,
+		Value:       value,
+		Description: description,
+		Short:       short,
+		Type:        OptionTypeString,
+	})
 }
 
+// The style of command-line parsing that an App uses
+type ParseStyle int
+
+const (
+	// The original style supported by this package: boolean options are "-name", and value options are
+	// "-name=value". There is no short/long distinction, and no "--" terminator.
+	StyleLegacy ParseStyle = iota
+
+	// GNU/POSIX style: long options are "--name", "--name=value" or "--name value"; short options are "-n",
+	// and may be clustered ("-abc" == "-a -b -c") or carry a value as "-nvalue" or "-n value". The "--"
+	// sentinel terminates option parsing, so everything after it is positional, even if it starts with "-".
+	StylePosix
+)
+
 // Application
 type App struct {
-	Description string     // Single-line description
-	DefaultExec ExecFunc   // Exec callback that is used if command's Exec is nil
-	Commands    []*Command // Commands
-	Options     []Option   // Global options
+	Description  string     // Single-line description
+	DefaultExec  ExecFunc   // Exec callback that is used if command's Exec is nil
+	Commands     []*Command // Commands
+	Options      []*Option  // Global options
+	Style        ParseStyle // Defaults to StyleLegacy, so existing callers are unaffected
+	NoCompletion bool       // If true, disables the automatic "completion" and hidden "__complete" commands
+	Stdout       io.Writer  // Where help text and other normal output is written. Defaults to os.Stdout
+	Stderr       io.Writer  // Where diagnostics about bad input are written. Defaults to os.Stderr
+}
+
+func (app *App) stdout() io.Writer {
+	if app.Stdout != nil {
+		return app.Stdout
+	}
+	return os.Stdout
+}
+
+func (app *App) stderr() io.Writer {
+	if app.Stderr != nil {
+		return app.Stderr
+	}
+	return os.Stderr
 }
 
-// Add an application-wide bool option (such as -z)
-func (app *App) AddBoolOption(name, description string) {
-	opt := Option{
+// The options that apply to a resolved command chain: the app's global options, followed by
+// each command's own options, from the top-level command down to the leaf.
+func (app *App) chainOptions(chain []*Command) []*Option {
+	all := append([]*Option{}, app.Options...)
+	for _, c := range chain {
+		all = append(all, c.Options...)
+	}
+	return all
+}
+
+// Add an application-wide bool option (such as -z). Returns the new Option so that
+// Required/Default/EnvVar can be set on it, eg app.AddBoolOption(...).Required = true
+func (app *App) AddBoolOption(name, description string) *Option {
+	return addOption(&app.Options, Option{
 		Key:         name,
 		Description: description,
-	}
-	app.Options = append(app.Options, opt)
+	})
+}
+
+// Add an application-wide value option (such as -c=config_file). Returns the new Option so that
+// Required/Default/EnvVar can be set on it, eg app.AddValueOption(...).Required = true
+func (app *App) AddValueOption(name, value, description string) *Option {
+	return addOption(&app.Options, Option{
+		Key:         name,
+		Value:       value,
+		Description: description,
+		Type:        OptionTypeString,
+	})
+}
+
+// Add an application-wide integer value option (such as -count=3). App.Run rejects a non-integer
+// value before exec is called. Returns the new Option so that Required/Default/EnvVar can be set on it.
+func (app *App) AddIntOption(name, value, description string) *Option {
+	return addOption(&app.Options, Option{
+		Key:         name,
+		Value:       value,
+		Description: description,
+		Type:        OptionTypeInt,
+	})
+}
+
+// Add an application-wide floating-point value option (such as -ratio=0.5). App.Run rejects a non-numeric
+// value before exec is called. Returns the new Option so that Required/Default/EnvVar can be set on it.
+func (app *App) AddFloatOption(name, value, description string) *Option {
+	return addOption(&app.Options, Option{
+		Key:         name,
+		Value:       value,
+		Description: description,
+		Type:        OptionTypeFloat,
+	})
+}
+
+// Add an application-wide duration value option (such as -timeout=30s), parsed with time.ParseDuration.
+// App.Run rejects an unparseable value before exec is called. Returns the new Option so that
+// Required/Default/EnvVar can be set on it.
+func (app *App) AddDurationOption(name, value, description string) *Option {
+	return addOption(&app.Options, Option{
+		Key:         name,
+		Value:       value,
+		Description: description,
+		Type:        OptionTypeDuration,
+	})
+}
+
+// Add an application-wide enum value option (such as -mode=fast), restricted to one of 'choices'.
+// App.Run rejects any other value before exec is called. Returns the new Option so that
+// Required/Default/EnvVar can be set on it.
+func (app *App) AddEnumOption(name string, choices []string, description string) *Option {
+	return addOption(&app.Options, Option{
+		Key:         name,
+		Value:       strings.Join(choices, "|"),
+		Description: description,
+		Type:        OptionTypeEnum,
+		Choices:     choices,
+	})
 }
 
-// Add an application-wide value option (such as -c=config_file)
-func (app *App) AddValueOption(name, value, description string) {
-	opt := Option{
+// Add an application-wide repeatable value option (such as -include=a.go -include=b.go). Retrieve the
+// accumulated values with OptionSet.StringSlice. Returns the new Option so that Required/Default/EnvVar
+// can be set on it.
+func (app *App) AddSliceOption(name, value, description string) *Option {
+	return addOption(&app.Options, Option{
 		Key:         name,
 		Value:       value,
 		Description: description,
+		Type:        OptionTypeSlice,
+	})
+}
+
+// Add an application-wide bool option with a short form (such as -v / --verbose). Only recognized
+// when App.Style is StylePosix. Returns the new Option so that Required/Default/EnvVar can be set on it.
+func (app *App) AddBoolOptionShort(name string, short rune, description string) *Option {
+	return addOption(&app.Options, Option{
+		Key:         name,
+		Description: description,
+		Short:       short,
+	})
+}
+
+// Add an application-wide value option with a short form (such as -o file / --output=file). Only
+// recognized when App.Style is StylePosix. Returns the new Option so that Required/Default/EnvVar
+// can be set on it.
+func (app *App) AddValueOptionShort(name string, short rune, value, description string) *Option {
+	return addOption(&app.Options, Option{
+		Key:         name,
+		Value:       value,
+		Description: description,
+		Short:       short,
+		Type:        OptionTypeString,
+	})
+}
+
+// Walk 'words' against the command tree, descending into child commands for
+// as long as each word matches a child of the current command. Returns the
+// deepest command reached (nil if the first word isn't a top-level command),
+// the chain of commands from the top-level command down to that command, and
+// the words that remain once no further child matches (these are the
+// command's arguments).
+func (app *App) resolve(words []string) (cmd *Command, chain []*Command, rest []string) {
+	cmds := app.Commands
+	for i, w := range words {
+		c := findCommand(cmds, w)
+		if c == nil {
+			return cmd, chain, words[i:]
+		}
+		cmd = c
+		chain = append(chain, c)
+		cmds = c.Commands
 	}
-	app.Options = append(app.Options, opt)
+	return cmd, chain, nil
 }
 
-// Execute a command list.
-func (app *App) Run() {
-	options := map[string]string{}
-	cmdName := ""
-	cmdArgs := []string{}
-	for iarg, arg := range os.Args {
-		if iarg == 0 {
-			// executable name
+// Parse 'args' (not including the executable name) into an options map and the
+// remaining positional words, according to app.Style. Under StylePosix, the set of
+// known options (used to decide which options take a value) grows as command words
+// are recognized, so that options declared on a subcommand are understood once that
+// subcommand's name has been seen.
+func (app *App) parseArgs(args []string) (options OptionSet, words []string, err error) {
+	options = OptionSet{}
+	words = []string{}
+
+	if app.Style != StylePosix {
+		for _, arg := range args {
+			if arg[0:1] == "-" {
+				equals := strings.Index(arg, "=")
+				if equals != -1 {
+					options.merge(arg[1:equals], arg[equals+1:])
+				} else {
+					options.merge(arg[1:], "")
+				}
+			} else {
+				words = append(words, arg)
+			}
+		}
+		return options, words, nil
+	}
+
+	cmds := app.Commands
+	// allOptions tracks the options declared on the command chain resolved so far
+	allOptions := append([]*Option{}, app.Options...)
+	terminated := false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !terminated && arg == "--" {
+			terminated = true
 			continue
 		}
-		if arg[0:1] == "-" {
-			equals := strings.Index(arg, "=")
-			if equals != -1 {
-				options[arg[1:equals]] = arg[equals+1:]
-			} else {
-				options[arg[1:]] = ""
+		if terminated || arg == "-" || arg[0:1] != "-" {
+			words = append(words, arg)
+			if c := findCommand(cmds, arg); c != nil {
+				allOptions = append(allOptions, c.Options...)
+				cmds = c.Commands
 			}
-		} else {
-			if cmdName == "" {
-				cmdName = arg
+			continue
+		}
+		if strings.HasPrefix(arg, "--") {
+			name := arg[2:]
+			value := ""
+			hasValue := false
+			if eq := strings.IndexByte(name, '='); eq != -1 {
+				value = name[eq+1:]
+				name = name[:eq]
+				hasValue = true
+			}
+			opt := findOption(allOptions, name)
+			if opt != nil && opt.Type != OptionTypeBool && !hasValue {
+				if i+1 >= len(args) {
+					return nil, nil, fmt.Errorf("%w: option --%v needs a value", ErrBadOption, name)
+				}
+				i++
+				value = args[i]
+			}
+			options.merge(name, value)
+			continue
+		}
+		// Short option(s), eg -n, -nvalue, -n value, or clustered -abc
+		runes := []rune(arg[1:])
+		for ri := 0; ri < len(runes); ri++ {
+			opt := findOptionShort(allOptions, runes[ri])
+			if opt == nil {
+				return nil, nil, fmt.Errorf("%w: unrecognized option -%c", ErrBadOption, runes[ri])
+			}
+			if opt.Type == OptionTypeBool {
+				options.merge(opt.Key, "")
+				continue
+			}
+			if ri+1 < len(runes) {
+				options.merge(opt.Key, string(runes[ri+1:]))
+			} else if i+1 < len(args) {
+				i++
+				options.merge(opt.Key, args[i])
 			} else {
-				cmdArgs = append(cmdArgs, arg)
+				return nil, nil, fmt.Errorf("%w: option -%c needs a value", ErrBadOption, runes[ri])
 			}
+			break
 		}
 	}
+	return options, words, nil
+}
+
+// RunArgs parses and executes the command described by 'args' (which, like os.Args[1:], does
+// not include the executable name), writing help text to app.Stdout and diagnostics to
+// app.Stderr. It returns nil on success. On failure, it returns one of the sentinel errors
+// declared above, wrapped with details about the specific command or option that triggered it.
+// If exec ran but returned a non-zero code, RunArgs returns an *ExecError carrying that code.
+// Unlike Run, RunArgs never reads os.Args and never calls os.Exit, which makes it suitable for
+// unit tests and for embedding this package's commands inside a larger program.
+func (app *App) RunArgs(args []string) error {
+	app.ensureCompletionCommand()
+
+	// The words after "__complete" are passed through unparsed, so that partially typed
+	// options (and an empty word being completed) reach runComplete intact.
+	if !app.NoCompletion && len(args) >= 1 && args[0] == "__complete" && app.find("__complete") == nil {
+		words := args[1:]
+		if len(words) >= 1 && words[0] == "--" {
+			words = words[1:]
+		}
+		app.runComplete(words)
+		return nil
+	}
+
+	options, words, err := app.parseArgs(args)
+	if err != nil {
+		fmt.Fprintln(app.stderr(), err)
+		return err
+	}
 
 	_, haveHelpOption := options["help"]
-	if cmdName == "" || cmdName == "help" || haveHelpOption {
-		//fmt.Printf("cmdArgs = %v\n", strings.Join(cmdArgs, ","))
-		if len(cmdArgs) >= 1 {
-			app.ShowHelp(cmdArgs[0])
+	if len(words) == 0 || words[0] == "help" || haveHelpOption {
+		if words != nil && len(words) >= 1 && words[0] == "help" {
+			app.ShowHelp(words[1:]...)
 		} else {
-			app.ShowHelp(cmdName)
+			app.ShowHelp(words...)
 		}
-		return
+		return ErrHelpRequested
+	}
+
+	cmd, chain, cmdArgs := app.resolve(words)
+	if cmd == nil {
+		err := fmt.Errorf("%w: '%v'", ErrUnknownCommand, words[0])
+		fmt.Fprintln(app.stderr(), err)
+		return err
 	}
 
-	cmd := app.find(cmdName)
-	if cmd != nil {
-		allOptions := append(app.Options, cmd.Options...)
-		isVArgs := isVarArgs(cmd.Args)
-		if isVArgs {
-			if len(cmdArgs) < len(cmd.Args)-1 {
-				fmt.Printf("%v arguments given, but %v needs '%v'\n", len(cmdArgs), cmdName, formatCmdArgs(cmd.Args))
-				return
+	if cmd.Exec == nil && len(cmd.Commands) != 0 {
+		// This is a parent command with no exec function of its own (eg "remote"
+		// in "remote add"/"remote remove"). Show its help instead of erroring out,
+		// even if app.DefaultExec is set - it only stands in for leaf commands.
+		app.ShowHelp(pathNames(chain)...)
+		return ErrHelpRequested
+	}
+
+	exec := cmd.Exec
+	if exec == nil {
+		exec = app.DefaultExec
+	}
+
+	cmdName := cmd.Name
+	allOptions := app.chainOptions(chain)
+
+	for _, opt := range allOptions {
+		if options.Has(opt.Key) {
+			continue
+		}
+		// A bool option carries no value - its presence is all that matters - so a
+		// Default/EnvVar fallback for one can only mean "treat it as if -key had been
+		// passed", never "pass it the literal fallback string" (which the later type
+		// check would then reject with "does not take a value").
+		boolValue := func(v string) string {
+			if opt.Type == OptionTypeBool {
+				return ""
 			}
-		} else if len(cmdArgs) != len(cmd.Args) {
-			fmt.Printf("%v arguments given, but %v needs '%v'\n", len(cmdArgs), cmdName, formatCmdArgs(cmd.Args))
-			return
+			return v
 		}
-		for key, value := range options {
-			opt := findOption(allOptions, key)
-			if opt == nil {
-				fmt.Printf("Unrecognized option %v\n", key)
-				return
-			} else if (opt.Value == "") && (value != "") {
-				fmt.Printf("Option %v does not take a value. Simply use -%v\n", opt.Key, opt.Key)
-				return
-			} else if (opt.Value != "") && (value == "") {
-				fmt.Printf("Option %v needs a value. Use -%v=%v\n", opt.Key, opt.Key, opt.Value)
-				return
+		if opt.EnvVar != "" {
+			if v, ok := os.LookupEnv(opt.EnvVar); ok {
+				options.merge(opt.Key, boolValue(v))
+				continue
 			}
 		}
-		exec := cmd.Exec
-		if exec == nil {
-			exec = app.DefaultExec
+		if opt.Default != "" {
+			options.merge(opt.Key, boolValue(opt.Default))
+			continue
 		}
-		if exec == nil {
-			fmt.Printf("No exec function specified for command '%v'\n", cmdName)
-			return
+		if opt.Required {
+			err := fmt.Errorf("%w: option -%v is required", ErrMissingArg, opt.Key)
+			fmt.Fprintln(app.stderr(), err)
+			return err
 		}
-		exec(cmdName, cmdArgs, options)
-	} else {
-		fmt.Printf("Unrecognized command '%v'\n", cmdName)
 	}
+
+	isVArgs := isVarArgs(cmd.Args)
+	if isVArgs {
+		if len(cmdArgs) < len(cmd.Args)-1 {
+			err := fmt.Errorf("%w: %v arguments given, but %v needs '%v'", ErrMissingArg, len(cmdArgs), cmdName, formatCmdArgs(cmd.Args))
+			fmt.Fprintln(app.stderr(), err)
+			return err
+		}
+	} else if len(cmdArgs) != len(cmd.Args) {
+		err := fmt.Errorf("%w: %v arguments given, but %v needs '%v'", ErrMissingArg, len(cmdArgs), cmdName, formatCmdArgs(cmd.Args))
+		fmt.Fprintln(app.stderr(), err)
+		return err
+	}
+	for key, value := range options {
+		opt := findOption(allOptions, key)
+		if opt == nil {
+			err := fmt.Errorf("%w: unrecognized option %v", ErrBadOption, key)
+			fmt.Fprintln(app.stderr(), err)
+			return err
+		} else if (opt.Type == OptionTypeBool) && (value != "") {
+			err := fmt.Errorf("%w: option %v does not take a value. Simply use -%v", ErrBadOption, opt.Key, opt.Key)
+			fmt.Fprintln(app.stderr(), err)
+			return err
+		} else if (opt.Type != OptionTypeBool) && (value == "") {
+			err := fmt.Errorf("%w: option %v needs a value. Use -%v=%v", ErrBadOption, opt.Key, opt.Key, opt.Value)
+			fmt.Fprintln(app.stderr(), err)
+			return err
+		} else if opt.Type != OptionTypeBool {
+			if verr := validateOptionValue(opt, value); verr != nil {
+				err := fmt.Errorf("%w: option -%v %v", ErrBadOption, opt.Key, verr)
+				fmt.Fprintln(app.stderr(), err)
+				return err
+			}
+		}
+	}
+	if exec == nil {
+		err := fmt.Errorf("%w: no exec function specified for command '%v'", ErrMissingArg, cmdName)
+		fmt.Fprintln(app.stderr(), err)
+		return err
+	}
+	if code := exec(cmdName, cmdArgs, options); code != 0 {
+		return &ExecError{Code: code}
+	}
+	return nil
+}
+
+// Run parses os.Args[1:] and executes the matching command, returning a process exit code:
+// 0 on success (including when help was shown), the code carried by exec if it failed, or 1
+// for any other error. It is a thin wrapper around RunArgs; use RunArgs directly to unit-test
+// command handling without touching os.Args, or to embed this package's commands in a larger
+// program that wants its own exit-code policy.
+func (app *App) Run() int {
+	return exitCode(app.RunArgs(os.Args[1:]))
+}
+
+// Maps an error returned by RunArgs to a process exit code.
+func exitCode(err error) int {
+	if err == nil || errors.Is(err, ErrHelpRequested) {
+		return 0
+	}
+	var execErr *ExecError
+	if errors.As(err, &execErr) {
+		return execErr.Code
+	}
+	return 1
 }
 
 func (app *App) AddCommand(name, description string, args ...string) *Command {
@@ -228,12 +813,7 @@ func (app *App) AddCommand(name, description string, args ...string) *Command {
 }
 
 func (app *App) find(cmdName string) *Command {
-	for i := range app.Commands {
-		if app.Commands[i].Name == cmdName {
-			return app.Commands[i]
-		}
-	}
-	return nil
+	return findCommand(app.Commands, cmdName)
 }
 
 func formatTextIntoLines(text string, firstLineIndent, otherLinesIndent int) []string {
@@ -256,13 +836,13 @@ func formatTextIntoLines(text string, firstLineIndent, otherLinesIndent int) []s
 	return lines
 }
 
-func writeBody(text string, firstLineIndent, otherLinesIndent int) {
+func writeBody(w io.Writer, text string, firstLineIndent, otherLinesIndent int) {
 	lines := formatTextIntoLines(text, firstLineIndent, otherLinesIndent)
 	for i, line := range lines {
 		if i == 0 {
-			fmt.Printf("%v%v\n", strings.Repeat(" ", firstLineIndent), line)
+			fmt.Fprintf(w, "%v%v\n", strings.Repeat(" ", firstLineIndent), line)
 		} else {
-			fmt.Printf("%v%v\n", strings.Repeat(" ", otherLinesIndent), line)
+			fmt.Fprintf(w, "%v%v\n", strings.Repeat(" ", otherLinesIndent), line)
 		}
 	}
 }
@@ -276,10 +856,21 @@ func formatCmdArgs(args []string) string {
 	}
 }
 
-// This is called automatically by Run().
-func (app *App) ShowHelp(cmdName string) {
+func showCommandList(w io.Writer, cmds []*Command, formatStr string) {
+	for _, c := range cmds {
+		fmt.Fprintf(w, formatStr, c.Name, c.ShortDescription())
+	}
+}
+
+// This is called automatically by RunArgs. 'cmdPath' is the sequence of words
+// following "help" on the command line (eg ["remote", "add"] for "myapp help
+// remote add"), and may be empty, in which case the top-level help is shown.
+// Output is written to app.Stdout.
+func (app *App) ShowHelp(cmdPath ...string) {
+	app.ensureCompletionCommand()
+	w := app.stdout()
 
-	findLongestOption := func(options []Option) int {
+	findLongestOption := func(options []*Option) int {
 		max := 0
 		for _, opt := range options {
 			length := 0
@@ -297,7 +888,7 @@ func (app *App) ShowHelp(cmdName string) {
 
 	optionFormatStr := ""
 
-	formatOption := func(opt Option) string {
+	formatOption := func(opt *Option) string {
 		if opt.Value != "" {
 			pair := fmt.Sprintf("%v=%v", opt.Key, opt.Value)
 			return fmt.Sprintf(optionFormatStr, pair)
@@ -306,26 +897,37 @@ func (app *App) ShowHelp(cmdName string) {
 		}
 	}
 
-	showOptions := func(options []Option) {
+	showOptions := func(options []*Option) {
 		longest := findLongestOption(options)
 		optionFormatStr = fmt.Sprintf("  -%%-%vv", longest)
-		fmt.Printf("\n")
+		fmt.Fprintf(w, "\n")
 		for _, opt := range options {
-			fmt.Printf("%v", formatOption(opt))
-			writeBody(opt.Description, 3, 6+longest)
+			fmt.Fprintf(w, "%v", formatOption(opt))
+			writeBody(w, annotateDescription(opt), 3, 6+longest)
 		}
 	}
 
-	cmd := app.find(cmdName)
-	if cmd != nil {
-		cmdAndArgs := cmd.Name + " " + formatCmdArgs(cmd.Args)
-		fmt.Printf("\n%v\n\n", cmdAndArgs)
-		writeBody(cmd.ShortDescription()+".", 2, 2)
+	cmd, chain, rest := app.resolve(cmdPath)
+	if cmd != nil && len(rest) == 0 {
+		cmdAndArgs := strings.Join(append(pathNames(chain), formatCmdArgs(cmd.Args)), " ")
+		fmt.Fprintf(w, "\n%v\n\n", strings.TrimRight(cmdAndArgs, " "))
+		writeBody(w, cmd.ShortDescription()+".", 2, 2)
 		if cmd.ExtraDescription() != "" {
-			writeBody(cmd.ExtraDescription(), 2, 2)
+			writeBody(w, cmd.ExtraDescription(), 2, 2)
+		}
+		if len(cmd.Commands) != 0 {
+			longestCmd := 0
+			for _, c := range cmd.Commands {
+				if len(c.Name) > longestCmd {
+					longestCmd = len(c.Name)
+				}
+			}
+			fmt.Fprintf(w, "\n")
+			showCommandList(w, cmd.Commands, fmt.Sprintf("  %%-%vv  %%v\n", longestCmd))
 		}
-		if len(cmd.Options) != 0 {
-			showOptions(cmd.Options)
+		allOptions := app.chainOptions(chain)
+		if len(allOptions) != 0 {
+			showOptions(allOptions)
 		}
 	} else {
 		longestCmd := 0
@@ -336,14 +938,21 @@ func (app *App) ShowHelp(cmdName string) {
 		}
 		cmdFormatStr := fmt.Sprintf("  %%-%vv  %%v\n", longestCmd)
 		if app.Description != "" {
-			fmt.Printf("\n%v\n\n", app.Description)
-		}
-		for _, c := range app.Commands {
-			fmt.Printf(cmdFormatStr, c.Name, c.ShortDescription())
+			fmt.Fprintf(w, "\n%v\n\n", app.Description)
 		}
+		showCommandList(w, app.Commands, cmdFormatStr)
 		if len(app.Options) != 0 {
 			showOptions(app.Options)
 		}
 	}
 
 }
+
+// The names of a command chain, from the top-level command down, eg ["remote", "add"]
+func pathNames(chain []*Command) []string {
+	names := make([]string, len(chain))
+	for i, c := range chain {
+		names[i] = c.Name
+	}
+	return names
+}
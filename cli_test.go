@@ -2,9 +2,13 @@
 package cli_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/IMQS/cli"
 )
@@ -56,3 +60,321 @@ func TestExampleApplication(t *testing.T) {
 		t.Fatal(`command "varargs" has failed`)
 	}
 }
+
+// Unlike TestExampleApplication, this exercises RunArgs directly, so it needs no os.Args
+// monkey-patching, and can inspect the diagnostics that App.Stderr receives.
+func TestRunArgs(t *testing.T) {
+	app := cli.App{}
+	app.DefaultExec = exec
+	app.AddCommand("start", "Start the application", "port", "root-directory")
+
+	if err := app.RunArgs([]string{"start", "/folder1/folder2", "8669"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var stderr bytes.Buffer
+	app.Stderr = &stderr
+	err := app.RunArgs([]string{"bogus"})
+	if !errors.Is(err, cli.ErrUnknownCommand) {
+		t.Fatalf("expected ErrUnknownCommand, got %v", err)
+	}
+	if stderr.Len() == 0 {
+		t.Fatal("expected a diagnostic to be written to App.Stderr")
+	}
+}
+
+// A parent command with subcommands but no Exec of its own should show help, even when
+// App.DefaultExec is set - DefaultExec only stands in for a leaf command's missing Exec.
+func TestRunArgsParentWithDefaultExec(t *testing.T) {
+	app := cli.App{}
+	app.DefaultExec = exec
+	remote := app.AddCommand("remote", "Manage remotes")
+	remote.AddCommand("add", "Add a remote", "name", "url")
+
+	var stdout bytes.Buffer
+	app.Stdout = &stdout
+	err := app.RunArgs([]string{"remote"})
+	if !errors.Is(err, cli.ErrHelpRequested) {
+		t.Fatalf("expected ErrHelpRequested, got %v", err)
+	}
+	if stdout.Len() == 0 {
+		t.Fatal("expected help to be written to App.Stdout")
+	}
+}
+
+// Exercises StylePosix: clustered short bools, the long form of a value option taking its
+// value from the next argument, and the "--" terminator that stops option parsing.
+func TestRunArgsPosix(t *testing.T) {
+	var got cli.OptionSet
+	posixExec := func(name string, args []string, options cli.OptionSet) int {
+		got = options
+		return 0
+	}
+
+	app := cli.App{}
+	app.Style = cli.StylePosix
+	pack := app.AddCommand("pack", "Pack some files", "...files")
+	pack.AddBoolOptionShort("all", 'a', "Include hidden files")
+	pack.AddBoolOptionShort("verbose", 'v', "Be verbose")
+	pack.AddValueOptionShort("output", 'o', "out.tar", "Output file")
+	pack.Exec = posixExec
+
+	if err := app.RunArgs([]string{"pack", "-av", "--output", "a.tar", "one.txt"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got.Has("all") || !got.Has("verbose") {
+		t.Fatalf("expected clustered short bools -a and -v to both be set, got %v", got)
+	}
+	if got.String("output") != "a.tar" {
+		t.Fatalf("expected --output a.tar, got %q", got.String("output"))
+	}
+
+	if err := app.RunArgs([]string{"pack", "--", "-not-an-option"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Has("all") || got.Has("verbose") || got.Has("output") {
+		t.Fatalf("expected '-not-an-option' after '--' not to be parsed as an option, got %v", got)
+	}
+}
+
+// Exercises the typed option values introduced for OptionSet: a valid value of each type is
+// accepted and retrievable through the matching accessor, and an invalid value is rejected by
+// RunArgs before exec is ever called.
+func TestRunArgsTypedOptions(t *testing.T) {
+	var got cli.OptionSet
+	run := cli.App{}
+	serve := run.AddCommand("serve", "Serve requests")
+	serve.AddIntOption("workers", "4", "Number of workers")
+	serve.AddFloatOption("ratio", "0.5", "Cache ratio")
+	serve.AddDurationOption("timeout", "30s", "Request timeout")
+	serve.AddEnumOption("mode", []string{"fast", "safe"}, "Run mode")
+	serve.AddSliceOption("include", "", "Paths to include, may be repeated")
+	serve.Exec = func(name string, args []string, options cli.OptionSet) int {
+		got = options
+		return 0
+	}
+
+	if err := run.RunArgs([]string{"serve", "-workers=8", "-ratio=0.75", "-timeout=1m", "-mode=fast", "-include=a", "-include=b"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n, err := got.Int("workers"); err != nil || n != 8 {
+		t.Fatalf("expected workers=8, got %v (err %v)", n, err)
+	}
+	if f, err := got.Float("ratio"); err != nil || f != 0.75 {
+		t.Fatalf("expected ratio=0.75, got %v (err %v)", f, err)
+	}
+	if d, err := got.Duration("timeout"); err != nil || d != time.Minute {
+		t.Fatalf("expected timeout=1m, got %v (err %v)", d, err)
+	}
+	if got.String("mode") != "fast" {
+		t.Fatalf("expected mode=fast, got %q", got.String("mode"))
+	}
+	if include := got.StringSlice("include"); len(include) != 2 || include[0] != "a" || include[1] != "b" {
+		t.Fatalf("expected include=[a b], got %v", include)
+	}
+
+	var stderr bytes.Buffer
+	run.Stderr = &stderr
+	err := run.RunArgs([]string{"serve", "-workers=notanumber"})
+	if !errors.Is(err, cli.ErrBadOption) {
+		t.Fatalf("expected ErrBadOption for a non-integer -workers, got %v", err)
+	}
+
+	stderr.Reset()
+	err = run.RunArgs([]string{"serve", "-mode=reckless"})
+	if !errors.Is(err, cli.ErrBadOption) {
+		t.Fatalf("expected ErrBadOption for an out-of-range -mode, got %v", err)
+	}
+}
+
+// Exercises Required, Default and EnvVar through the builder API: each AddXxxOption
+// constructor returns the new *Option, so they can be set by chaining off the call.
+func TestRunArgsOptionDefaults(t *testing.T) {
+	var got cli.OptionSet
+	app := cli.App{}
+	deploy := app.AddCommand("deploy", "Deploy the application")
+	deploy.AddValueOption("region", "us-east", "Target region").Default = "us-west"
+	deploy.AddValueOption("token", "secret", "Auth token").EnvVar = "DEPLOY_TOKEN"
+	deploy.AddValueOption("target", "host", "Deploy target").Required = true
+	deploy.Exec = func(name string, args []string, options cli.OptionSet) int {
+		got = options
+		return 0
+	}
+
+	os.Setenv("DEPLOY_TOKEN", "from-env")
+	defer os.Unsetenv("DEPLOY_TOKEN")
+
+	if err := app.RunArgs([]string{"deploy", "-target=prod"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.String("region") != "us-west" {
+		t.Fatalf("expected -region to fall back to its Default, got %q", got.String("region"))
+	}
+	if got.String("token") != "from-env" {
+		t.Fatalf("expected -token to fall back to its EnvVar, got %q", got.String("token"))
+	}
+
+	var stderr bytes.Buffer
+	app.Stderr = &stderr
+	err := app.RunArgs([]string{"deploy"})
+	if !errors.Is(err, cli.ErrMissingArg) {
+		t.Fatalf("expected ErrMissingArg for a missing Required option, got %v", err)
+	}
+}
+
+// A bool option carries no value, so a Default or EnvVar fallback for one must set it as if
+// -key had been passed, not merge the fallback string as the option's value - otherwise the
+// later "does not take a value" check rejects it on every invocation.
+func TestRunArgsBoolOptionDefaults(t *testing.T) {
+	var got cli.OptionSet
+	app := cli.App{}
+	run := app.AddCommand("run", "Run the application")
+	run.AddBoolOption("verbose", "Be verbose").Default = "true"
+	run.AddBoolOption("color", "Colorize output").EnvVar = "APP_COLOR"
+	run.Exec = func(name string, args []string, options cli.OptionSet) int {
+		got = options
+		return 0
+	}
+
+	os.Setenv("APP_COLOR", "1")
+	defer os.Unsetenv("APP_COLOR")
+
+	if err := app.RunArgs([]string{"run"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got.Has("verbose") {
+		t.Fatal("expected -verbose to fall back to its Default")
+	}
+	if !got.Has("color") {
+		t.Fatal("expected -color to fall back to its EnvVar")
+	}
+}
+
+// GenerateCompletion should produce a script that at least references the program name, for
+// each supported shell, and reject a shell it doesn't know how to generate for.
+func TestGenerateCompletion(t *testing.T) {
+	app := cli.App{}
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var buf bytes.Buffer
+		if err := app.GenerateCompletion(shell, &buf); err != nil {
+			t.Fatalf("%v: unexpected error: %v", shell, err)
+		}
+		if buf.Len() == 0 {
+			t.Fatalf("%v: expected a non-empty completion script", shell)
+		}
+	}
+	if err := app.GenerateCompletion("powershell", &bytes.Buffer{}); err == nil {
+		t.Fatal("expected an error for an unsupported shell")
+	}
+}
+
+// Exercises the hidden "__complete" command that the generated completion scripts call into,
+// both for subcommand names and for an enum option's values.
+func TestRunArgsComplete(t *testing.T) {
+	app := cli.App{}
+	app.Style = cli.StylePosix
+	serve := app.AddCommand("serve", "Serve requests")
+	serve.AddEnumOption("mode", []string{"fast", "safe"}, "Run mode")
+	app.AddCommand("stop", "Stop the application")
+
+	var stdout bytes.Buffer
+	app.Stdout = &stdout
+	if err := app.RunArgs([]string{"__complete", "--", "s"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := stdout.String()
+	if !strings.Contains(got, "serve") || !strings.Contains(got, "stop") {
+		t.Fatalf("expected completions for 'serve' and 'stop', got %q", got)
+	}
+
+	stdout.Reset()
+	if err := app.RunArgs([]string{"__complete", "--", "serve", "--mode", "f"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := stdout.String(); strings.TrimSpace(got) != "fast" {
+		t.Fatalf("expected only 'fast' to complete '--mode f', got %q", got)
+	}
+}
+
+// Exercises GenerateMan and GenerateMarkdown, including the required/default/env annotations
+// that both share with ShowHelp via annotateDescription.
+func TestGenerateDocs(t *testing.T) {
+	app := cli.App{}
+	app.Description = "myapp does things"
+	app.AddValueOption("port", "8080", "Listen port").Required = true
+	app.AddCommand("serve", "Serve requests")
+
+	var man bytes.Buffer
+	if err := app.GenerateMan(1, &man); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(man.String(), ".SH OPTIONS") || !strings.Contains(man.String(), "(required)") {
+		t.Fatalf("expected man page to document -port as required, got %q", man.String())
+	}
+
+	var md bytes.Buffer
+	if err := app.GenerateMarkdown(&md); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(md.String(), "myapp does things") || !strings.Contains(md.String(), "(required)") {
+		t.Fatalf("expected markdown to document -port as required, got %q", md.String())
+	}
+}
+
+// The automatic "completion" command must be a genuine *Command, so that ShowHelp, GenerateMan,
+// GenerateMarkdown and self-completion via "__complete" all agree that it exists, instead of only
+// RunArgs recognizing the literal word.
+func TestCompletionCommandRegistered(t *testing.T) {
+	app := cli.App{}
+	app.AddCommand("serve", "Serve requests")
+
+	var help bytes.Buffer
+	app.Stdout = &help
+	app.ShowHelp()
+	if !strings.Contains(help.String(), "completion") {
+		t.Fatalf("expected top-level help to list the completion command, got %q", help.String())
+	}
+
+	var man bytes.Buffer
+	if err := app.GenerateMan(1, &man); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(man.String(), "completion") {
+		t.Fatalf("expected man page to document the completion command, got %q", man.String())
+	}
+
+	var md bytes.Buffer
+	if err := app.GenerateMarkdown(&md); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(md.String(), "completion") {
+		t.Fatalf("expected markdown to document the completion command, got %q", md.String())
+	}
+
+	var stdout bytes.Buffer
+	app.Stdout = &stdout
+	if err := app.RunArgs([]string{"__complete", "--", "c"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := stdout.String(); strings.TrimSpace(got) != "completion" {
+		t.Fatalf("expected 'completion' to self-complete from 'c', got %q", got)
+	}
+}
+
+// When GenerateMan switches to one page per top-level command, a leaf command's own page must
+// document its Args in SYNOPSIS, the same way GenerateMarkdown's "Usage:" line does - not the
+// generic "COMMAND [ARGS...]" placeholder that only makes sense for a command with subcommands.
+func TestGenerateManLeafCommandArgs(t *testing.T) {
+	app := cli.App{}
+	remote := app.AddCommand("remote", "Manage remotes")
+	remote.AddCommand("add", "Add a remote", "name", "url")
+	app.AddCommand("serve", "Serve requests", "config-file")
+
+	var man bytes.Buffer
+	if err := app.GenerateMan(1, &man); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(man.String(), "config-file") {
+		t.Fatalf("expected serve's man page to document its config-file argument, got %q", man.String())
+	}
+}
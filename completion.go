@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const bashCompletionTemplate = `_%[1]v_complete() {
+	local words=("${COMP_WORDS[@]:1}")
+	COMPREPLY=($(%[1]v __complete -- "${words[@]}"))
+}
+complete -F _%[1]v_complete %[1]v
+`
+
+const zshCompletionTemplate = `#compdef %[1]v
+
+_%[1]v() {
+	local -a words completions
+	words=("${words[@]:1}")
+	completions=("${(@f)$(%[1]v __complete -- "${words[@]}")}")
+	compadd -a completions
+}
+
+compdef _%[1]v %[1]v
+`
+
+const fishCompletionTemplate = `function __%[1]v_complete
+	set -l tokens (commandline -opc) (commandline -ct)
+	%[1]v __complete -- $tokens[2..-1]
+end
+
+complete -c %[1]v -f -a '(__%[1]v_complete)'
+`
+
+// Registers the automatic "completion" command on app.Commands, unless app.NoCompletion is set
+// or the app already declares its own "completion" command. Called before app.Commands is read
+// by anything that needs to see it consistently - RunArgs, ShowHelp, GenerateMan, GenerateMarkdown -
+// so that help, documentation and self-completion all agree with what RunArgs actually accepts.
+func (app *App) ensureCompletionCommand() {
+	if app.NoCompletion || app.find("completion") != nil {
+		return
+	}
+	cmd := &Command{
+		Name:        "completion",
+		Description: "Print a shell completion script\nEg 'source <(myapp completion bash)' enables tab completion for bash.",
+		Args:        []string{"...shell"},
+	}
+	cmd.Exec = func(name string, args []string, options OptionSet) int {
+		shell := ""
+		if len(args) > 0 {
+			shell = args[0]
+		}
+		if err := app.GenerateCompletion(shell, app.stdout()); err != nil {
+			fmt.Fprintln(app.stderr(), err)
+			return 1
+		}
+		return 0
+	}
+	app.Commands = append(app.Commands, cmd)
+}
+
+// Writes a static shell-completion script for 'shell' ("bash", "zsh", or "fish") to 'w'. The
+// script itself does no completion logic - it simply forwards the words typed so far to the
+// hidden "__complete" command, which prints one completion candidate per line.
+func (app *App) GenerateCompletion(shell string, w io.Writer) error {
+	name := app.execName()
+	switch shell {
+	case "bash":
+		fmt.Fprintf(w, bashCompletionTemplate, name)
+	case "zsh":
+		fmt.Fprintf(w, zshCompletionTemplate, name)
+	case "fish":
+		fmt.Fprintf(w, fishCompletionTemplate, name)
+	default:
+		return fmt.Errorf("unsupported shell '%v'. Expected one of bash, zsh, fish", shell)
+	}
+	return nil
+}
+
+// The name that the generated completion scripts use to invoke this program
+func (app *App) execName() string {
+	if len(os.Args) == 0 {
+		return "app"
+	}
+	parts := strings.Split(os.Args[0], "/")
+	return parts[len(parts)-1]
+}
+
+// Implements the hidden "__complete" command. 'words' are the argv words typed so far, not
+// including the program name or "__complete" itself. The last word is the one being completed,
+// and may be empty. One completion candidate is printed per line.
+func (app *App) runComplete(words []string) {
+	w := app.stdout()
+	prefix := ""
+	if len(words) > 0 {
+		prefix = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	cmd, chain, _ := app.resolve(words)
+	allOptions := app.chainOptions(chain)
+
+	// If the previous word is a value option, we're completing its value, not a new word.
+	if len(words) > 0 {
+		if opt := app.optionForFlag(allOptions, words[len(words)-1]); opt != nil && opt.Type != OptionTypeBool {
+			app.printValueCandidates(w, opt, prefix)
+			return
+		}
+	}
+
+	if strings.HasPrefix(prefix, "-") {
+		app.printOptionCandidates(w, allOptions, prefix)
+		return
+	}
+
+	cmds := app.Commands
+	if cmd != nil {
+		cmds = cmd.Commands
+	}
+	for _, c := range cmds {
+		if strings.HasPrefix(c.Name, prefix) {
+			fmt.Fprintln(w, c.Name)
+		}
+	}
+}
+
+// If 'flag' looks like an already-typed option, returns the Option it refers to, respecting
+// app.Style (long/short forms under StylePosix, single-dash forms under StyleLegacy).
+func (app *App) optionForFlag(options []*Option, flag string) *Option {
+	if !strings.HasPrefix(flag, "-") || flag == "-" {
+		return nil
+	}
+	if app.Style == StylePosix {
+		if strings.HasPrefix(flag, "--") {
+			return findOption(options, flag[2:])
+		}
+		if len(flag) == 2 {
+			return findOptionShort(options, rune(flag[1]))
+		}
+		return nil
+	}
+	return findOption(options, flag[1:])
+}
+
+func (app *App) printValueCandidates(w io.Writer, opt *Option, prefix string) {
+	if opt.Complete != nil {
+		for _, v := range opt.Complete(prefix) {
+			fmt.Fprintln(w, v)
+		}
+		return
+	}
+	if opt.Type == OptionTypeEnum {
+		for _, choice := range opt.Choices {
+			if strings.HasPrefix(choice, prefix) {
+				fmt.Fprintln(w, choice)
+			}
+		}
+	}
+}
+
+func (app *App) printOptionCandidates(w io.Writer, options []*Option, prefix string) {
+	for _, opt := range options {
+		if app.Style == StylePosix {
+			if long := "--" + opt.Key; strings.HasPrefix(long, prefix) {
+				fmt.Fprintln(w, long)
+			}
+			if opt.Short != 0 {
+				if short := "-" + string(opt.Short); strings.HasPrefix(short, prefix) {
+					fmt.Fprintln(w, short)
+				}
+			}
+		} else if flag := "-" + opt.Key; strings.HasPrefix(flag, prefix) {
+			fmt.Fprintln(w, flag)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GenerateMan writes a groff man page (or pages) describing this App's commands and options,
+// suitable for installing as /usr/share/man/manN/<name>.N. 'section' is the man section number
+// (eg 1 for user commands). If none of the App's top-level commands have subcommands of their
+// own, a single page is written, covering every command. Otherwise, one full page is written per
+// top-level command, one after another, since a single page describing a deeply nested command
+// tree (eg "myapp remote add") quickly becomes unreadable.
+func (app *App) GenerateMan(section int, w io.Writer) error {
+	app.ensureCompletionCommand()
+	name := app.execName()
+
+	nested := false
+	for _, c := range app.Commands {
+		if len(c.Commands) != 0 {
+			nested = true
+			break
+		}
+	}
+
+	if !nested {
+		app.writeManPage(w, name, section, name, app.Description, app.Commands, nil, app.Options)
+		return nil
+	}
+
+	for i, c := range app.Commands {
+		if i != 0 {
+			fmt.Fprint(w, "\n")
+		}
+		title := fmt.Sprintf("%v-%v", name, c.Name)
+		options := app.chainOptions([]*Command{c})
+		app.writeManPage(w, title, section, name+" "+c.Name, c.ShortDescription(), c.Commands, c.Args, options)
+	}
+	return nil
+}
+
+// Writes a single groff page, headed "TITLE(section)", whose SYNOPSIS begins with 'invocation'
+// (eg "myapp" or "myapp remote"), whose COMMANDS section lists 'cmds', and whose OPTIONS
+// section lists 'options'. 'args' is only used when 'cmds' is empty - the own arguments of a
+// leaf command, formatted the same way GenerateMarkdown's "Usage:" line does.
+func (app *App) writeManPage(w io.Writer, title string, section int, invocation, description string, cmds []*Command, args []string, options []*Option) {
+	fmt.Fprintf(w, `.TH "%v" "%v" "" "" ""%v`, strings.ToUpper(title), section, "\n")
+
+	fmt.Fprintf(w, ".SH NAME\n%v", manEscape(title))
+	if description != "" {
+		fmt.Fprintf(w, " \\- %v", manEscape(description))
+	}
+	fmt.Fprint(w, "\n")
+
+	switch {
+	case len(cmds) != 0:
+		fmt.Fprintf(w, ".SH SYNOPSIS\n.B %v\n[OPTIONS] COMMAND [ARGS...]\n", manEscape(invocation))
+	case len(args) != 0:
+		fmt.Fprintf(w, ".SH SYNOPSIS\n.B %v\n[OPTIONS] %v\n", manEscape(invocation), manEscape(formatCmdArgs(args)))
+	default:
+		fmt.Fprintf(w, ".SH SYNOPSIS\n.B %v\n[OPTIONS]\n", manEscape(invocation))
+	}
+
+	if description != "" {
+		fmt.Fprintf(w, ".SH DESCRIPTION\n%v\n", manEscape(description))
+	}
+
+	if len(cmds) != 0 {
+		fmt.Fprint(w, ".SH COMMANDS\n")
+		for _, c := range cmds {
+			fmt.Fprintf(w, ".TP\n.B %v\n%v\n", manEscape(c.Name), manEscape(c.ShortDescription()))
+		}
+	}
+
+	if len(options) != 0 {
+		fmt.Fprint(w, ".SH OPTIONS\n")
+		for _, opt := range options {
+			fmt.Fprintf(w, ".TP\n.BR -%v\n%v\n", manEscape(opt.Key), manEscape(annotateDescription(opt)))
+		}
+	}
+}
+
+// Escapes characters that are significant to groff (backslash, and a leading dot or
+// apostrophe, which groff would otherwise interpret as a request).
+func manEscape(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n.br\n")
+}
+
+// GenerateMarkdown writes a single Markdown document describing this App's commands and
+// options, walking the command tree recursively - a command with subcommands gets a deeper
+// heading level for each of them. This is intended for rendering on a project's website or
+// wiki, where App.GenerateMan's groff output isn't appropriate.
+func (app *App) GenerateMarkdown(w io.Writer) error {
+	app.ensureCompletionCommand()
+	name := app.execName()
+	fmt.Fprintf(w, "# %v\n\n", name)
+	if app.Description != "" {
+		fmt.Fprintf(w, "%v\n\n", app.Description)
+	}
+	if len(app.Options) != 0 {
+		fmt.Fprint(w, "## Global options\n\n")
+		writeMarkdownOptions(w, app.Options)
+	}
+	if len(app.Commands) != 0 {
+		fmt.Fprint(w, "## Commands\n\n")
+		for _, c := range app.Commands {
+			writeMarkdownCommand(w, c, []string{name}, 3)
+		}
+	}
+	return nil
+}
+
+// Writes the section for 'cmd', and recurses into its subcommands, increasing the heading
+// 'level' (the number of '#' characters) by one at each level of nesting. 'path' is the
+// sequence of words that invoke 'cmd', not including 'cmd' itself.
+func writeMarkdownCommand(w io.Writer, cmd *Command, path []string, level int) {
+	heading := strings.Repeat("#", level)
+	invocation := strings.Join(append(append([]string{}, path...), cmd.Name), " ")
+	fmt.Fprintf(w, "%v %v\n\n", heading, invocation)
+	if cmd.ShortDescription() != "" {
+		fmt.Fprintf(w, "%v\n\n", cmd.ShortDescription())
+	}
+	if cmd.ExtraDescription() != "" {
+		fmt.Fprintf(w, "%v\n\n", cmd.ExtraDescription())
+	}
+	if len(cmd.Args) != 0 {
+		fmt.Fprintf(w, "Usage: `%v %v`\n\n", invocation, formatCmdArgs(cmd.Args))
+	}
+	if len(cmd.Options) != 0 {
+		writeMarkdownOptions(w, cmd.Options)
+	}
+	childPath := append(append([]string{}, path...), cmd.Name)
+	for _, child := range cmd.Commands {
+		writeMarkdownCommand(w, child, childPath, level+1)
+	}
+}
+
+func writeMarkdownOptions(w io.Writer, options []*Option) {
+	for _, opt := range options {
+		fmt.Fprintf(w, "- `-%v`", opt.Key)
+		if opt.Value != "" {
+			fmt.Fprintf(w, "=%v", opt.Value)
+		}
+		desc := annotateDescription(opt)
+		if desc != "" {
+			fmt.Fprintf(w, " - %v", desc)
+		}
+		fmt.Fprint(w, "\n")
+	}
+	fmt.Fprint(w, "\n")
+}